@@ -0,0 +1,126 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+// scopeContextKey is the context.Context key a *TraceScope is stored under
+// by ScopeFromContext, so that a later call on a descendant context finds
+// its parent scope.
+type scopeContextKey struct{}
+
+// remoteContextKey is the context.Context key a propagation.SpanContext
+// extracted from an inbound request is stored under, via ContextWithRemote
+// or Extract, for ScopeFromContext to pick up when it mints a root scope.
+type remoteContextKey struct{}
+
+// ScopeFromContext returns a scope for name, parented either to the scope
+// already carried by ctx (if any, from an earlier ScopeFromContext call up
+// the call stack), or to the remote trace extracted into ctx by Extract /
+// ContextWithRemote (if any), or else starts a brand new trace.  The
+// returned context.Context carries the new scope, so passing it to further
+// ScopeFromContext calls continues the same trace.
+//
+// If ctx's scope belongs to a different Tracer than src - e.g. it was
+// started by an httpTracer and a dbTracer now wants to continue it for
+// "db.query" - the new scope is NOT opened via parent.Sub, which would
+// record it onto the parent's Tracer instead of src.  Instead src starts a
+// fresh scope that still carries the same trace id and sampling decision,
+// the same way it would for an inbound remote traceparent.
+func (src *Tracer) ScopeFromContext(ctx context.Context, name string) (*TraceScope, context.Context) {
+	var sc *TraceScope
+	parent, hasParent := ctx.Value(scopeContextKey{}).(*TraceScope)
+	switch {
+	case hasParent && parent.trc == src:
+		sc = parent.Sub(name)
+	case hasParent:
+		sc = newRemoteScope(src, parent.spanContext(), name)
+	default:
+		if remote, ok := ctx.Value(remoteContextKey{}).(propagation.SpanContext); ok && remote.IsValid() {
+			sc = newRemoteScope(src, remote, name)
+		} else {
+			sc = newScope(src, nil, name)
+		}
+	}
+	return sc, context.WithValue(ctx, scopeContextKey{}, sc)
+}
+
+// ContextWithRemote attaches a remote SpanContext to ctx, for a later
+// ScopeFromContext call to start a root scope from.  Transports that
+// receive a W3C traceparent typically call Extract instead, which does
+// this for them.
+func ContextWithRemote(ctx context.Context, sc propagation.SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// Extract reads a W3C traceparent/tracestate pair from carrier and, if
+// valid, returns a context.Context carrying it for ScopeFromContext to
+// pick up. If carrier has no valid traceparent, ctx is returned unchanged
+// and the next ScopeFromContext call starts a fresh trace.
+func Extract(ctx context.Context, carrier propagation.Carrier) context.Context {
+	sc, _ := propagation.Extract(carrier)
+	if !sc.IsValid() {
+		return ctx
+	}
+	return ContextWithRemote(ctx, sc)
+}
+
+// Inject writes this scope's W3C traceparent header into carrier, so that
+// an outgoing request continues the same trace.
+func (sc *TraceScope) Inject(carrier propagation.Carrier) {
+	propagation.Inject(sc.spanContext(), "", carrier)
+}
+
+// spanContext builds the propagation.SpanContext for this scope, as seen
+// by a downstream callee: this scope's id becomes the parent span id of
+// whatever the callee starts next.
+func (sc *TraceScope) spanContext() propagation.SpanContext {
+	return propagation.SpanContext{
+		TraceID: sc.top.traceID,
+		SpanID:  spanIDFromUint64(sc.id),
+		Sampled: sc.sampled,
+	}
+}
+
+func spanIDFromUint64(id uint64) propagation.SpanID {
+	var b propagation.SpanID
+	binary.BigEndian.PutUint64(b[:], id)
+	return b
+}
+
+// newRemoteScope starts a root scope continuing a trace whose id and
+// sampling decision were extracted from a remote traceparent, rather than
+// minting a fresh trace id.
+func newRemoteScope(trc *Tracer, remote propagation.SpanContext, name string) *TraceScope {
+	sc := newScope(trc, nil, name)
+	sc.traceID = remote.TraceID
+	sc.remoteParent = remote.SpanID
+	// Honor the upstream service's sampling decision rather than
+	// re-rolling our own: a trace that was sampled there should stay
+	// sampled here, and vice versa, so every hop agrees.
+	sc.sampled = remote.Sampled
+	return sc
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"container/heap"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Finish idempotently closes the scope: the first call behaves like Close,
+// and every later call (including one racing a fired WithDeadline timer)
+// is a no-op.  It exists so that callers who aren't sure whether a scope
+// has already been ended some other way (an error path, a deadline) can
+// still unconditionally close it, e.g. from a defer.
+func (sc *TraceScope) Finish() *TraceScope {
+	sc.finish(func() { sc.Close() })
+	return sc
+}
+
+// finish is the single place idempotent completion happens: emit runs the
+// actual closing record (ErrorName for a panic or deadline, Close
+// otherwise), but only for the first caller to win the CAS; see Finish and
+// WithDeadline.
+func (sc *TraceScope) finish(emit func()) {
+	if !atomic.CompareAndSwapInt32(&sc.finished, 0, 1) {
+		return
+	}
+	if sc.deadline != nil {
+		deadlines.cancel(sc.deadline)
+	}
+	emit()
+}
+
+// StartScope opens a new root scope named name with the given Open args,
+// and returns it along with a cleanup closure meant to be deferred:
+//
+//	sc, done := tracer.StartScope("handleRequest")
+//	defer done()
+//
+// The closure recovers a panic (if any), emits it as an errRecord named
+// "panic" with the recovered value and a stack trace, then re-panics so
+// the panic still propagates normally; either way, it always finishes the
+// scope exactly once.
+func (src *Tracer) StartScope(name string, args ...interface{}) (*TraceScope, func()) {
+	sc := src.Scope(name).Open(args...)
+	done := func() {
+		if r := recover(); r != nil {
+			sc.finish(func() {
+				sc.ErrorName("panic", fmt.Errorf("%v", r), string(debug.Stack()))
+			})
+			panic(r)
+		}
+		sc.Finish()
+	}
+	return sc, done
+}
+
+// WithDeadline arranges for an errRecord named "deadline_exceeded" to be
+// emitted if Finish has not been called within d; the pending deadline is
+// cancelled as soon as Finish runs, so a scope that finishes on time never
+// pays for it beyond the one scheduling call.  It returns sc for chaining,
+// e.g. tracer.Scope("work").WithDeadline(time.Second).Open().
+func (sc *TraceScope) WithDeadline(d time.Duration) *TraceScope {
+	sc.deadline = deadlines.schedule(sc, d)
+	return sc
+}
+
+// deadlineEntry is one pending WithDeadline timeout.
+type deadlineEntry struct {
+	at    time.Time
+	d     time.Duration
+	scope *TraceScope
+	index int // position in the deadlineHeap, maintained by container/heap
+}
+
+// deadlineHeap is a min-heap of deadlineEntry ordered by at, so the
+// scheduler's single timer only ever needs to know about the soonest one.
+type deadlineHeap []*deadlineEntry
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h deadlineHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
+func (h *deadlineHeap) Push(x interface{}) {
+	e := x.(*deadlineEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *deadlineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// deadlineScheduler runs a single background goroutine that fires
+// WithDeadline timeouts for every scope in the process, rather than one
+// timer goroutine per deadline.
+type deadlineScheduler struct {
+	mu   sync.Mutex
+	heap deadlineHeap
+	wake chan struct{}
+}
+
+// deadlines is the process-wide deadline scheduler used by WithDeadline.
+// Its background goroutine is started from init rather than inline here:
+// run (via fireDue, finish) eventually calls back into deadlines.cancel,
+// and starting the goroutine as part of this initializer would make that
+// a variable initialization cycle.
+var deadlines = &deadlineScheduler{wake: make(chan struct{}, 1)}
+
+func init() {
+	go deadlines.run()
+}
+
+func (s *deadlineScheduler) schedule(sc *TraceScope, d time.Duration) *deadlineEntry {
+	e := &deadlineEntry{at: time.Now().Add(d), d: d, scope: sc}
+	s.mu.Lock()
+	heap.Push(&s.heap, e)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return e
+}
+
+func (s *deadlineScheduler) cancel(e *deadlineEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.index < 0 || e.index >= len(s.heap) || s.heap[e.index] != e {
+		return
+	}
+	heap.Remove(&s.heap, e.index)
+}
+
+func (s *deadlineScheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		wait := time.Hour
+		if len(s.heap) > 0 {
+			if w := time.Until(s.heap[0].at); w > 0 {
+				wait = w
+			} else {
+				wait = 0
+			}
+		}
+		s.mu.Unlock()
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+			s.fireDue()
+		case <-s.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (s *deadlineScheduler) fireDue() {
+	now := time.Now()
+	var due []*deadlineEntry
+	s.mu.Lock()
+	for len(s.heap) > 0 && !s.heap[0].at.After(now) {
+		due = append(due, heap.Pop(&s.heap).(*deadlineEntry))
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		sc := e.scope
+		sc.finish(func() {
+			sc.ErrorName("deadline_exceeded", fmt.Errorf("scope %q exceeded its %s deadline", sc.name, e.d))
+		})
+	}
+}
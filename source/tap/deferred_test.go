@@ -0,0 +1,143 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFinishIsIdempotent(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("finish-test")
+	trc.SetWatcher(w)
+
+	sc := trc.Scope("op").Open()
+	sc.Finish()
+	sc.Finish()
+	sc.Finish()
+
+	var ends int
+	for _, item := range w.snapshot() {
+		sr, _ := AsRecord(item)
+		if sr.Type == endRecord.String() {
+			ends++
+		}
+	}
+	if ends != 1 {
+		t.Errorf("got %d end records from 3 Finish calls, want 1", ends)
+	}
+}
+
+func TestStartScopeRecoversAndRepanics(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("panic-test")
+	trc.SetWatcher(w)
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		sc, done := trc.StartScope("op")
+		defer done()
+		_ = sc
+		panic("boom")
+	}()
+
+	if recovered != "boom" {
+		t.Fatalf("recovered = %v, want the panic to propagate past done()", recovered)
+	}
+
+	var found bool
+	for _, item := range w.snapshot() {
+		sr, ok := AsRecord(item)
+		if ok && sr.Err == "boom" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("no errRecord with the panic value was emitted")
+	}
+}
+
+func TestStartScopeFinishesNormallyWithoutPanic(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("normal-test")
+	trc.SetWatcher(w)
+
+	func() {
+		_, done := trc.StartScope("op")
+		defer done()
+	}()
+
+	var ends int
+	for _, item := range w.snapshot() {
+		sr, _ := AsRecord(item)
+		if sr.Type == endRecord.String() {
+			ends++
+		}
+	}
+	if ends != 1 {
+		t.Errorf("got %d end records, want 1", ends)
+	}
+}
+
+func TestWithDeadlineFiresWhenNotFinished(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("deadline-test")
+	trc.SetWatcher(w)
+
+	trc.Scope("slow").WithDeadline(20 * time.Millisecond).Open()
+
+	deadline := time.After(time.Second)
+	for {
+		var found bool
+		for _, item := range w.snapshot() {
+			if sr, ok := AsRecord(item); ok && sr.Err != "" {
+				found = true
+			}
+		}
+		if found {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for deadline_exceeded record")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestWithDeadlineCancelledByFinish(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("deadline-cancel-test")
+	trc.SetWatcher(w)
+
+	sc := trc.Scope("fast").WithDeadline(50 * time.Millisecond).Open()
+	sc.Finish()
+
+	time.Sleep(100 * time.Millisecond)
+
+	for _, item := range w.snapshot() {
+		if sr, ok := AsRecord(item); ok && sr.Err != "" {
+			t.Fatalf("got an error record after Finish cancelled the deadline: %+v", sr)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Field is a single structured key/value attribute, for the *KV scope
+// methods.  Unlike the free-form Info/Open/Close/Error variadic args
+// (dumped with %v into a single string), fields keep their key and typed
+// value, so they survive into JSON and map cleanly onto span attributes
+// for the otlp exporter.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string-valued Field.
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int64 returns an int64-valued Field.
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool returns a bool-valued Field.
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration returns a time.Duration-valued Field.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Stringer returns a Field whose value is rendered via fmt.Stringer.
+func Stringer(key string, value fmt.Stringer) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Error returns an error-valued Field under the conventional key "error".
+func Error(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// InfoKV emits an info record with the given structured fields.
+func (sc *TraceScope) InfoKV(fields ...Field) *TraceScope {
+	return sc.emitRecord(infoRecord, fieldArgs(fields))
+}
+
+// OpenKV emits a begin record with the given structured fields.
+func (sc *TraceScope) OpenKV(fields ...Field) *TraceScope {
+	return sc.emitRecord(beginRecord, fieldArgs(fields))
+}
+
+// CloseKV emits an end record with the given structured fields.
+func (sc *TraceScope) CloseKV(fields ...Field) *TraceScope {
+	return sc.emitRecord(endRecord, fieldArgs(fields))
+}
+
+// ErrorKV emits an error record with the given error and structured fields.
+func (sc *TraceScope) ErrorKV(err error, fields ...Field) *TraceScope {
+	return sc.ErrorNameKV("", err, fields...)
+}
+
+// ErrorNameKV emits a named error record with the given error and
+// structured fields.
+func (sc *TraceScope) ErrorNameKV(name string, err error, fields ...Field) *TraceScope {
+	return sc.emitRecord(errRecord, errArgs{name, err, fieldArgs(fields)})
+}
+
+// fieldArgs is the structured counterpart to genericArgs: a record.Args
+// value built from Field slices rather than bare interface{}s.
+type fieldArgs []Field
+
+func (args fieldArgs) String() string {
+	parts := make([]string, len(args))
+	for i, f := range args {
+		parts[i] = fmt.Sprintf("%s=%v", f.Key, f.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Map flattens fieldArgs into a map[string]interface{}, the shape
+// downstream JSON consumers and the otlp exporter want to query by key.
+func (args fieldArgs) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(args))
+	for _, f := range args {
+		m[f.Key] = f.Value
+	}
+	return m
+}
+
+// MarshalJSON renders fieldArgs as a JSON object keyed by field name,
+// instead of the array of {Key,Value} pairs a naive marshal would produce.
+func (args fieldArgs) MarshalJSON() ([]byte, error) {
+	return json.Marshal(args.Map())
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// captureWatcher records every item handed to it by a Tracer, for
+// inspection via AsRecord.  HandleItem can be called from a goroutine other
+// than the one that set up the watcher - e.g. WithDeadline's background
+// scheduler (see deferred.go) - so access to items is mutex-guarded; use
+// snapshot to read it rather than the field directly.
+type captureWatcher struct {
+	mu    sync.Mutex
+	items []interface{}
+}
+
+func (w *captureWatcher) Active() bool { return true }
+
+func (w *captureWatcher) HandleItem(item interface{}) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.items = append(w.items, item)
+	return true
+}
+
+// snapshot returns a copy of the items recorded so far, safe to range over
+// from the test goroutine while HandleItem may still be called concurrently.
+func (w *captureWatcher) snapshot() []interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]interface{}(nil), w.items...)
+}
+
+func TestFieldConstructors(t *testing.T) {
+	cases := []Field{
+		String("key", "value"),
+		Int64("count", 42),
+		Bool("ok", true),
+		Duration("elapsed", 0),
+		Error(errors.New("boom")),
+	}
+	want := []string{"key", "count", "ok", "elapsed", "error"}
+	for i, f := range cases {
+		if f.Key != want[i] {
+			t.Errorf("case %d key = %q, want %q", i, f.Key, want[i])
+		}
+	}
+}
+
+func TestInfoKVFieldsReachWatcher(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("fields-test")
+	trc.SetWatcher(w)
+
+	trc.Scope("op").InfoKV(String("user", "alice"), Int64("retries", 2))
+
+	items := w.snapshot()
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	sr, ok := AsRecord(items[0])
+	if !ok {
+		t.Fatal("AsRecord failed on InfoKV record")
+	}
+	if sr.Fields["user"] != "alice" || sr.Fields["retries"] != int64(2) {
+		t.Errorf("Fields = %+v, want user=alice retries=2", sr.Fields)
+	}
+}
+
+func TestErrorKVReachesWatcherWithFieldsAndErr(t *testing.T) {
+	w := &captureWatcher{}
+	trc := NewTracer("fields-test-err")
+	trc.SetWatcher(w)
+
+	trc.Scope("op").ErrorKV(errors.New("disk full"), String("path", "/tmp/x"))
+
+	items := w.snapshot()
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	sr, ok := AsRecord(items[0])
+	if !ok {
+		t.Fatal("AsRecord failed on ErrorKV record")
+	}
+	if sr.Err != "disk full" {
+		t.Errorf("Err = %q, want %q", sr.Err, "disk full")
+	}
+	if sr.Fields["path"] != "/tmp/x" {
+		t.Errorf("Fields = %+v, want path=/tmp/x", sr.Fields)
+	}
+}
+
+func TestFieldArgsMapAndString(t *testing.T) {
+	args := fieldArgs{String("a", "1"), Int64("b", 2)}
+
+	m := args.Map()
+	if m["a"] != "1" || m["b"] != int64(2) {
+		t.Errorf("Map() = %+v, want a=1 b=2", m)
+	}
+
+	if got, want := args.String(), "a=1 b=2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	b, err := args.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if got, want := string(b), `{"a":"1","b":2}`; got != want {
+		t.Errorf("MarshalJSON() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package memstore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uber-go/gwr/source"
+)
+
+// defaultTextFormat renders a Trace as an indented waterfall: the root
+// span first, then each descendant indented under its parent, in the order
+// their first record reached the store, with each line's begin offset
+// (relative to the trace's begin) and duration.
+var defaultTextFormat = source.GenericDataFormat(formatTrace)
+
+func formatTrace(item interface{}) string {
+	tr, ok := item.(Trace)
+	if !ok {
+		return fmt.Sprintf("%v", item)
+	}
+
+	byParent := make(map[uint64][]Span, len(tr.Spans))
+	var root Span
+	for _, sp := range tr.Spans {
+		byParent[sp.ParentID] = append(byParent[sp.ParentID], sp)
+		if sp.ParentID == 0 {
+			root = sp
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "trace %s (%s)\n", tr.TraceID, tr.Duration())
+	writeWaterfall(&b, root, byParent, tr.Begin, 0)
+	return b.String()
+}
+
+func writeWaterfall(b *strings.Builder, sp Span, byParent map[uint64][]Span, traceBegin time.Time, depth int) {
+	indent := strings.Repeat("  ", depth)
+	errSuffix := ""
+	if sp.Err != "" {
+		errSuffix = fmt.Sprintf(" !!! %s", sp.Err)
+	}
+	fmt.Fprintf(b, "%s%s +%s (%s)%s\n", indent, sp.Name, sp.Begin.Sub(traceBegin), sp.Duration(), errSuffix)
+	for _, child := range byParent[sp.SpanID] {
+		writeWaterfall(b, child, byParent, traceBegin, depth+1)
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package memstore retains recently completed tap traces in memory and
+// exposes them as their own gwr data source, so operators get a
+// zero-dependency "recent slow requests" view without running a
+// collector.  Attach it to a Tracer with Tracer.SetWatcher(memstore.New()),
+// then add the Store itself as a gwr source with gwr.AddGenericDataSource.
+package memstore
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uber-go/gwr/source"
+	"github.com/uber-go/gwr/source/tap"
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+const (
+	defaultName     = "/tap/traces"
+	defaultCapacity = 1024
+	shardCount      = 16
+)
+
+// Span is one completed scope within a retained Trace.
+type Span struct {
+	SpanID   uint64
+	ParentID uint64 // zero for the trace's root span
+	Name     string
+	Begin    time.Time
+	End      time.Time
+	Fields   map[string]interface{}
+	Err      string
+}
+
+// Duration is how long the span was open.
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Begin)
+}
+
+// Trace is a retained, completed root trace: its root span plus every
+// descendant span that had closed by the time the root did.  Spans that
+// are still open when the root closes are retained too, with a zero End.
+type Trace struct {
+	TraceID propagation.TraceID
+	Name    string // root span's name
+	Begin   time.Time
+	End     time.Time
+	Spans   []Span // in the order each span's first record was ingested: root first when it was opened before its children
+}
+
+// Duration is how long the root span was open.
+func (t Trace) Duration() time.Duration {
+	return t.End.Sub(t.Begin)
+}
+
+// Store is a ring-buffered, in-memory trace store.  It implements both
+// source.GenericDataWatcher (so a Tracer can feed it) and the same minimal
+// gwr data source shape as tap.Tracer (so it can be exposed at its own
+// path, e.g. "/tap/traces").  The zero value is not usable; use New.
+type Store struct {
+	name   string
+	shards [shardCount]*shard
+}
+
+// Option configures a Store constructed by New.
+type Option func(*Store)
+
+// WithName overrides the gwr source name; the default is "/tap/traces".
+func WithName(name string) Option {
+	return func(s *Store) { s.name = name }
+}
+
+// WithCapacity overrides how many completed traces are retained per shard;
+// the total retained is shardCount * capacity. The default is 1024 per
+// shard.
+func WithCapacity(capacity int) Option {
+	return func(s *Store) {
+		for _, sh := range s.shards {
+			sh.capacity = capacity
+		}
+	}
+}
+
+// New creates a Store with its ring buffers ready to receive spans.
+func New(opts ...Option) *Store {
+	s := &Store{name: defaultName}
+	for i := range s.shards {
+		s.shards[i] = &shard{capacity: defaultCapacity}
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, sh := range s.shards {
+		sh.traces = make([]Trace, 0, sh.capacity)
+		sh.building = make(map[uint64]*buildingTrace)
+	}
+	return s
+}
+
+// Name returns the gwr source name of the store.
+func (s *Store) Name() string {
+	return s.name
+}
+
+// Formats returns the store's gwr formats: a "text" format that renders a
+// single Trace as an indented parent/child waterfall.
+func (s *Store) Formats() map[string]source.GenericDataFormat {
+	return map[string]source.GenericDataFormat{"text": defaultTextFormat}
+}
+
+// SetWatcher sets the watcher that live updates (newly completed traces)
+// are forwarded to, the same role a Tracer's watcher plays.
+func (s *Store) SetWatcher(watcher source.GenericDataWatcher) {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.watcher = watcher
+		sh.mu.Unlock()
+	}
+}
+
+// Active always returns true: a Store has to keep ingesting regardless of
+// whether anyone is currently querying it, so that a trace is available
+// once someone does look.
+func (s *Store) Active() bool {
+	return true
+}
+
+// HandleItem implements source.GenericDataWatcher, so a Store can be
+// attached directly via Tracer.SetWatcher.
+func (s *Store) HandleItem(item interface{}) bool {
+	rec, ok := tap.AsRecord(item)
+	if !ok {
+		return false
+	}
+	s.shardFor(rec.ScopeID).ingest(rec)
+	return true
+}
+
+func (s *Store) shardFor(scopeID uint64) *shard {
+	return s.shards[scopeID%shardCount]
+}
+
+// Query filters retained traces.  Any of the Query fields left at its zero
+// value is not used to filter.
+type Query struct {
+	TraceID     propagation.TraceID
+	Name        string
+	MinDuration time.Duration
+	Limit       int
+}
+
+// Find returns retained traces matching q, most recently completed first.
+func (s *Store) Find(q Query) []Trace {
+	var out []Trace
+	for _, sh := range s.shards {
+		out = append(out, sh.find(q)...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].End.After(out[j].End) })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out
+}
+
+// ParseQuery builds a Query from the "/tap/traces" URL query parameters:
+// trace_id (32 hex digits), name (exact match), min_duration (a
+// time.ParseDuration string, e.g. "50ms"), and limit (a non-negative
+// integer; 0 means no limit).
+// Any parameter that's absent or empty is left at its Query zero value, so
+// it doesn't filter.
+func ParseQuery(params url.Values) (Query, error) {
+	var q Query
+	if v := params.Get("trace_id"); v != "" {
+		raw, err := hex.DecodeString(v)
+		if err != nil || len(raw) != len(q.TraceID) {
+			return Query{}, fmt.Errorf("memstore: invalid trace_id %q", v)
+		}
+		copy(q.TraceID[:], raw)
+	}
+	q.Name = params.Get("name")
+	if v := params.Get("min_duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Query{}, fmt.Errorf("memstore: invalid min_duration %q: %w", v, err)
+		}
+		q.MinDuration = d
+	}
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Query{}, fmt.Errorf("memstore: invalid limit %q", v)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// Get parses params with ParseQuery and returns the matching retained
+// traces; this is what an HTTP GET against the "/tap/traces" gwr source,
+// with its query string, should call.
+func (s *Store) Get(params url.Values) ([]Trace, error) {
+	q, err := ParseQuery(params)
+	if err != nil {
+		return nil, err
+	}
+	return s.Find(q), nil
+}
+
+// buildingTrace accumulates spans for a trace that hasn't finished yet
+// (its root scope is still open).
+type buildingTrace struct {
+	traceID propagation.TraceID
+	name    string
+	begin   time.Time
+	order   []uint64
+	spans   map[uint64]*Span
+	seq     uint64 // insertion order into shard.building, for eviction
+}
+
+type shard struct {
+	mu       sync.Mutex
+	capacity int
+	watcher  source.GenericDataWatcher
+	building map[uint64]*buildingTrace
+	nextSeq  uint64
+	traces   []Trace // ring buffer; once full, traces[writeAt] is the oldest slot
+	writeAt  int
+}
+
+func (sh *shard) ingest(rec tap.SpanRecord) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	bt, ok := sh.building[rec.ScopeID]
+	if !ok {
+		// A scope whose root is never Closed (forgotten Close, or a panic
+		// that skips it) would otherwise sit in building forever, growing
+		// it without bound - the one thing the ring-buffered traces slice
+		// is bounded against. Cap building the same way: once it's full,
+		// the oldest in-flight trace is dropped to make room.
+		if len(sh.building) >= sh.capacity {
+			sh.evictOldestBuilding()
+		}
+		bt = &buildingTrace{traceID: rec.TraceID, spans: make(map[uint64]*Span), seq: sh.nextSeq}
+		sh.nextSeq++
+		sh.building[rec.ScopeID] = bt
+	}
+	sp, ok := bt.spans[rec.SpanID]
+	if !ok {
+		sp = &Span{SpanID: rec.SpanID}
+		if rec.HasParent {
+			sp.ParentID = rec.ParentID
+		}
+		bt.spans[rec.SpanID] = sp
+		bt.order = append(bt.order, rec.SpanID)
+	}
+	if rec.Name != "" {
+		sp.Name = rec.Name
+	}
+	if rec.Fields != nil {
+		if sp.Fields == nil {
+			sp.Fields = make(map[string]interface{}, len(rec.Fields))
+		}
+		for k, v := range rec.Fields {
+			sp.Fields[k] = v
+		}
+	}
+
+	switch rec.Type {
+	case "begin":
+		if sp.Begin.IsZero() {
+			sp.Begin = rec.Time
+		}
+		if rec.SpanID == rec.ScopeID {
+			bt.name = rec.Name
+			bt.begin = sp.Begin
+		}
+	case "error":
+		sp.Err = rec.Err
+		fallthrough
+	case "end":
+		sp.End = rec.Time
+	}
+
+	if rec.SpanID == rec.ScopeID && !sp.End.IsZero() {
+		sh.complete(bt)
+		delete(sh.building, rec.ScopeID)
+	}
+}
+
+// evictOldestBuilding drops the longest-incomplete in-flight trace from
+// building. It's only reached once building is at capacity, which in
+// practice means scopes are being opened and never closed faster than this
+// shard can hold them - so there's no good spans to keep here, just the
+// oldest leak to make room for the newest one.
+func (sh *shard) evictOldestBuilding() {
+	var oldestID uint64
+	var oldestSeq uint64
+	first := true
+	for id, bt := range sh.building {
+		if first || bt.seq < oldestSeq {
+			oldestID, oldestSeq, first = id, bt.seq, false
+		}
+	}
+	if !first {
+		delete(sh.building, oldestID)
+	}
+}
+
+func (sh *shard) complete(bt *buildingTrace) {
+	spans := make([]Span, 0, len(bt.order))
+	for _, id := range bt.order {
+		spans = append(spans, *bt.spans[id])
+	}
+	root := bt.spans[bt.order[0]]
+	tr := Trace{
+		TraceID: bt.traceID,
+		Name:    bt.name,
+		Begin:   bt.begin,
+		End:     root.End,
+		Spans:   spans,
+	}
+	sh.store(tr)
+	if sh.watcher != nil && sh.watcher.Active() {
+		sh.watcher.HandleItem(tr)
+	}
+}
+
+func (sh *shard) store(tr Trace) {
+	if len(sh.traces) < sh.capacity {
+		sh.traces = append(sh.traces, tr)
+		return
+	}
+	sh.traces[sh.writeAt] = tr
+	sh.writeAt = (sh.writeAt + 1) % sh.capacity
+}
+
+func (sh *shard) find(q Query) []Trace {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	var out []Trace
+	for _, tr := range sh.traces {
+		if !q.TraceID.IsZero() && tr.TraceID != q.TraceID {
+			continue
+		}
+		if q.Name != "" && tr.Name != q.Name {
+			continue
+		}
+		if tr.Duration() < q.MinDuration {
+			continue
+		}
+		out = append(out, tr)
+	}
+	return out
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package memstore
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/uber-go/gwr/source/tap"
+)
+
+func newTestTrace(s *Store, name string, sleep time.Duration) {
+	trc := tap.NewTracer(name)
+	trc.SetWatcher(s)
+	root := trc.Scope(name).Open()
+	child := root.Sub(name + ".child").Open()
+	time.Sleep(sleep)
+	child.Close()
+	root.Close()
+}
+
+func TestStoreRetainsCompletedTraceWithSpans(t *testing.T) {
+	s := New()
+	newTestTrace(s, "checkout", 0)
+
+	got := s.Find(Query{})
+	if len(got) != 1 {
+		t.Fatalf("got %d traces, want 1", len(got))
+	}
+	tr := got[0]
+	if tr.Name != "checkout" {
+		t.Errorf("Name = %q, want %q", tr.Name, "checkout")
+	}
+	if len(tr.Spans) != 2 {
+		t.Fatalf("got %d spans, want 2 (root + child)", len(tr.Spans))
+	}
+	if tr.Spans[0].ParentID != 0 {
+		t.Errorf("root span ParentID = %d, want 0", tr.Spans[0].ParentID)
+	}
+	if tr.Spans[1].ParentID != tr.Spans[0].SpanID {
+		t.Errorf("child span ParentID = %d, want root's SpanID %d", tr.Spans[1].ParentID, tr.Spans[0].SpanID)
+	}
+}
+
+func TestFindFiltersByNameAndMinDurationAndLimit(t *testing.T) {
+	s := New()
+	newTestTrace(s, "fast", 0)
+	newTestTrace(s, "slow", 30*time.Millisecond)
+
+	if got := s.Find(Query{Name: "slow"}); len(got) != 1 || got[0].Name != "slow" {
+		t.Errorf("Find(Name=slow) = %+v, want just the slow trace", got)
+	}
+
+	if got := s.Find(Query{MinDuration: 20 * time.Millisecond}); len(got) != 1 || got[0].Name != "slow" {
+		t.Errorf("Find(MinDuration=20ms) = %+v, want just the slow trace", got)
+	}
+
+	if got := s.Find(Query{Limit: 1}); len(got) != 1 {
+		t.Errorf("Find(Limit=1) returned %d traces, want 1", len(got))
+	}
+
+	if got := s.Find(Query{}); len(got) != 2 {
+		t.Errorf("Find({}) returned %d traces, want 2", len(got))
+	}
+}
+
+func TestFindFiltersByTraceID(t *testing.T) {
+	s := New()
+	newTestTrace(s, "a", 0)
+	newTestTrace(s, "b", 0)
+
+	all := s.Find(Query{})
+	if len(all) != 2 {
+		t.Fatalf("got %d traces, want 2", len(all))
+	}
+
+	got := s.Find(Query{TraceID: all[0].TraceID})
+	if len(got) != 1 || got[0].TraceID != all[0].TraceID {
+		t.Errorf("Find(TraceID=%s) = %+v, want just that trace", all[0].TraceID, got)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(url.Values{
+		"trace_id":     {"0af7651916cd43dd8448eb211c80319c"},
+		"name":         {"checkout"},
+		"min_duration": {"50ms"},
+		"limit":        {"10"},
+	})
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+	if got, want := q.TraceID.String(), "0af7651916cd43dd8448eb211c80319c"; got != want {
+		t.Errorf("TraceID = %s, want %s", got, want)
+	}
+	if q.Name != "checkout" {
+		t.Errorf("Name = %q, want %q", q.Name, "checkout")
+	}
+	if q.MinDuration != 50*time.Millisecond {
+		t.Errorf("MinDuration = %s, want 50ms", q.MinDuration)
+	}
+	if q.Limit != 10 {
+		t.Errorf("Limit = %d, want 10", q.Limit)
+	}
+}
+
+func TestParseQueryEmpty(t *testing.T) {
+	q, err := ParseQuery(url.Values{})
+	if err != nil {
+		t.Fatalf("ParseQuery({}) error = %v", err)
+	}
+	if q != (Query{}) {
+		t.Errorf("ParseQuery({}) = %+v, want the zero Query", q)
+	}
+}
+
+func TestParseQueryMalformed(t *testing.T) {
+	cases := map[string]url.Values{
+		"short trace_id":       {"trace_id": {"deadbeef"}},
+		"non-hex trace_id":     {"trace_id": {"zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz"}},
+		"invalid min_duration": {"min_duration": {"not-a-duration"}},
+		"non-numeric limit":    {"limit": {"abc"}},
+		"negative limit":       {"limit": {"-1"}},
+	}
+	for name, params := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseQuery(params); err == nil {
+				t.Errorf("ParseQuery(%v) error = nil, want an error", params)
+			}
+		})
+	}
+}
+
+func TestGetParsesAndFilters(t *testing.T) {
+	s := New()
+	newTestTrace(s, "checkout", 0)
+
+	got, err := s.Get(url.Values{"name": {"checkout"}})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d traces, want 1", len(got))
+	}
+
+	if _, err := s.Get(url.Values{"limit": {"abc"}}); err == nil {
+		t.Error("Get() with an invalid limit should return an error")
+	}
+}
+
+func TestShardBuildingEvictsOldestIncompleteTrace(t *testing.T) {
+	sh := &shard{capacity: 2, building: make(map[uint64]*buildingTrace)}
+
+	for _, scopeID := range []uint64{1, 2, 3} {
+		sh.ingest(tap.SpanRecord{ScopeID: scopeID, SpanID: scopeID, Type: "begin", Name: "leaked"})
+	}
+
+	if len(sh.building) != 2 {
+		t.Fatalf("got %d in-flight traces, want 2 (capacity)", len(sh.building))
+	}
+	if _, ok := sh.building[1]; ok {
+		t.Error("oldest in-flight trace (scope 1) should have been evicted once capacity was exceeded")
+	}
+	if _, ok := sh.building[2]; !ok {
+		t.Error("scope 2 should still be in-flight")
+	}
+	if _, ok := sh.building[3]; !ok {
+		t.Error("scope 3 should still be in-flight")
+	}
+}
+
+func TestShardRingBufferEvictsOldest(t *testing.T) {
+	sh := &shard{capacity: 2, traces: make([]Trace, 0, 2)}
+	sh.store(Trace{Name: "a"})
+	sh.store(Trace{Name: "b"})
+	sh.store(Trace{Name: "c"})
+
+	if len(sh.traces) != 2 {
+		t.Fatalf("got %d retained traces, want 2 (capacity)", len(sh.traces))
+	}
+	names := map[string]bool{}
+	for _, tr := range sh.traces {
+		names[tr.Name] = true
+	}
+	if names["a"] {
+		t.Error("oldest trace (a) should have been evicted once capacity was exceeded")
+	}
+	if !names["b"] || !names["c"] {
+		t.Errorf("expected b and c to still be retained, got %v", sh.traces)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package otlp implements a tap.SpanExporter that ships trace scopes to any
+// OTLP/HTTP collector (Jaeger, Tempo, the upstream otel-collector, ...) as
+// opentelemetry.proto.trace.v1.TracesData, without requiring the protobuf
+// runtime or generated stubs as a dependency.
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uber-go/gwr/source/tap"
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+const (
+	spanKindInternal = 1
+
+	defaultMaxBatch    = 512
+	defaultFlushEvery  = 5 * time.Second
+	defaultContentType = "application/x-protobuf"
+)
+
+// span is the exporter's working representation of one in-flight or
+// completed scope, built up across StartSpan/AddEvent/SetStatus/EndSpan
+// calls before being handed to encodeSpan.
+type span struct {
+	traceID       [16]byte
+	spanID        [8]byte
+	parentSpanID  *[8]byte
+	name          string
+	kind          int
+	startUnixNano uint64
+	endUnixNano   uint64
+	statusErr     string
+	events        []byte // pre-encoded Event messages, concatenated
+}
+
+// Exporter batches spans in memory and flushes them to an OTLP/HTTP
+// collector, either when the batch reaches MaxBatch spans or every
+// FlushEvery, whichever comes first.  An Exporter must be created with New;
+// call Close to flush and stop its background goroutine.
+type Exporter struct {
+	endpoint   string
+	client     *http.Client
+	maxBatch   int
+	flushEvery time.Duration
+
+	mu      sync.Mutex
+	pending map[[8]byte]*span // started, not yet ended
+	ready   []span            // ended, waiting to be flushed
+
+	flush chan struct{}
+	done  chan struct{}
+}
+
+// Option configures an Exporter constructed by New.
+type Option func(*Exporter)
+
+// WithHTTPClient overrides the http.Client used to POST batches; the
+// default is http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) { e.client = c }
+}
+
+// WithMaxBatch overrides how many completed spans accumulate before an
+// immediate flush is triggered.
+func WithMaxBatch(n int) Option {
+	return func(e *Exporter) { e.maxBatch = n }
+}
+
+// WithFlushInterval overrides how often pending spans are flushed even if
+// MaxBatch hasn't been reached.
+func WithFlushInterval(d time.Duration) Option {
+	return func(e *Exporter) { e.flushEvery = d }
+}
+
+// New creates an Exporter that POSTs OTLP/HTTP protobuf batches to
+// endpoint, e.g. "http://localhost:4318/v1/traces".
+func New(endpoint string, opts ...Option) *Exporter {
+	e := &Exporter{
+		endpoint:   endpoint,
+		client:     http.DefaultClient,
+		maxBatch:   defaultMaxBatch,
+		flushEvery: defaultFlushEvery,
+		pending:    make(map[[8]byte]*span),
+		flush:      make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	go e.loop()
+	return e
+}
+
+var _ tap.SpanExporter = (*Exporter)(nil)
+
+// StartSpan implements tap.SpanExporter.
+func (e *Exporter) StartSpan(sd tap.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sp := &span{
+		traceID:       traceIDFor(sd.TraceID),
+		spanID:        spanIDFor(sd.SpanID),
+		name:          sd.Name,
+		kind:          spanKindInternal,
+		startUnixNano: uint64(sd.Start.UnixNano()),
+	}
+	switch {
+	case sd.ParentID != 0:
+		id := spanIDFor(sd.ParentID)
+		sp.parentSpanID = &id
+	case !sd.RemoteParentID.IsZero():
+		// A root scope that continues a trace started elsewhere (an
+		// inbound traceparent, or a cross-Tracer ScopeFromContext
+		// continuation) has no local ParentID, but it still has an
+		// upstream span to attach under.
+		id := [8]byte(sd.RemoteParentID)
+		sp.parentSpanID = &id
+	}
+	e.pending[sp.spanID] = sp
+}
+
+// AddEvent implements tap.SpanExporter.
+func (e *Exporter) AddEvent(sd tap.SpanData, name string, args interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sp, ok := e.pending[spanIDFor(sd.SpanID)]
+	if !ok {
+		return
+	}
+	sp.events = append(sp.events, encodeEvent(uint64(time.Now().UnixNano()), name)...)
+}
+
+// SetStatus implements tap.SpanExporter.
+func (e *Exporter) SetStatus(sd tap.SpanData, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sp, ok := e.pending[spanIDFor(sd.SpanID)]
+	if !ok || err == nil {
+		return
+	}
+	sp.statusErr = err.Error()
+}
+
+// EndSpan implements tap.SpanExporter.
+func (e *Exporter) EndSpan(sd tap.SpanData) {
+	e.mu.Lock()
+	id := spanIDFor(sd.SpanID)
+	sp, ok := e.pending[id]
+	if !ok {
+		e.mu.Unlock()
+		return
+	}
+	delete(e.pending, id)
+	sp.endUnixNano = uint64(sd.End.UnixNano())
+	e.ready = append(e.ready, *sp)
+	full := len(e.ready) >= e.maxBatch
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close flushes any remaining spans and stops the background flush loop.
+func (e *Exporter) Close() error {
+	close(e.done)
+	e.flushNow()
+	return nil
+}
+
+func (e *Exporter) loop() {
+	t := time.NewTicker(e.flushEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			e.flushNow()
+		case <-e.flush:
+			e.flushNow()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *Exporter) flushNow() {
+	e.mu.Lock()
+	batch := e.ready
+	e.ready = nil
+	e.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+	body := encodeTracesData(batch)
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", defaultContentType)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// traceIDFor converts a tap propagation.TraceID - the same 128-bit id
+// carried over the wire in a W3C traceparent - to the plain [16]byte the
+// wire encoder wants. Using this id (rather than the process-local scope
+// counter) is what lets two services exporting the same distributed call
+// land in the same trace in the collector.
+func traceIDFor(id propagation.TraceID) [16]byte {
+	return [16]byte(id)
+}
+
+func spanIDFor(id uint64) [8]byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], id)
+	return b
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"testing"
+
+	"github.com/uber-go/gwr/source/tap"
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+func TestStartSpanUsesRemoteParentIDWhenNoLocalParent(t *testing.T) {
+	e := New("http://example.invalid/v1/traces")
+	defer e.Close()
+
+	remote := propagation.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	e.StartSpan(tap.SpanData{
+		TraceID:        propagation.NewTraceID(),
+		SpanID:         1,
+		RemoteParentID: remote,
+		Name:           "handle",
+	})
+
+	e.mu.Lock()
+	sp, ok := e.pending[spanIDFor(1)]
+	e.mu.Unlock()
+	if !ok {
+		t.Fatal("span 1 was not recorded as pending")
+	}
+	if sp.parentSpanID == nil {
+		t.Fatal("parentSpanID is nil, want the remote parent's span id")
+	}
+	if *sp.parentSpanID != [8]byte(remote) {
+		t.Errorf("parentSpanID = %x, want %x", *sp.parentSpanID, remote)
+	}
+
+	fields := decodeMessage(t, encodeSpan(*sp))
+	got := fields[spanFieldParentSpanID][0].bytes
+	if string(got) != string(remote[:]) {
+		t.Errorf("encoded parent_span_id = %x, want %x", got, remote[:])
+	}
+}
+
+func TestStartSpanPrefersLocalParentIDOverRemote(t *testing.T) {
+	e := New("http://example.invalid/v1/traces")
+	defer e.Close()
+
+	e.StartSpan(tap.SpanData{
+		TraceID:        propagation.NewTraceID(),
+		SpanID:         2,
+		ParentID:       7,
+		RemoteParentID: propagation.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		Name:           "child",
+	})
+
+	e.mu.Lock()
+	sp, ok := e.pending[spanIDFor(2)]
+	e.mu.Unlock()
+	if !ok {
+		t.Fatal("span 2 was not recorded as pending")
+	}
+	want := spanIDFor(7)
+	if sp.parentSpanID == nil || *sp.parentSpanID != want {
+		t.Errorf("parentSpanID = %v, want local parent id %x", sp.parentSpanID, want)
+	}
+}
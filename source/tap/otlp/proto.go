@@ -0,0 +1,188 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+// This file hand-encodes the small slice of the OpenTelemetry trace proto
+// (opentelemetry.proto.trace.v1.TracesData) that the exporter needs, using
+// the protobuf wire format directly.  We do this instead of depending on a
+// generated pb.go / the protobuf runtime so that wiring tap into an OTLP
+// collector doesn't pull in a proto toolchain just for a handful of fields.
+// See https://protobuf.dev/programming-guides/encoding/ for the wire format.
+
+const (
+	wireVarint = iota
+	wireFixed64
+	wireBytes
+	_ // wireStartGroup, deprecated
+	_ // wireEndGroup, deprecated
+	wireFixed32
+)
+
+// pbuf accumulates protobuf wire bytes for one message.
+type pbuf struct {
+	b []byte
+}
+
+func (p *pbuf) tag(field int, wire int) {
+	p.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (p *pbuf) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *pbuf) fixed64(v uint64) {
+	for i := 0; i < 8; i++ {
+		p.b = append(p.b, byte(v))
+		v >>= 8
+	}
+}
+
+func (p *pbuf) int64Field(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	p.tag(field, wireVarint)
+	p.varint(uint64(v))
+}
+
+func (p *pbuf) fixed64Field(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	p.tag(field, wireFixed64)
+	p.fixed64(v)
+}
+
+func (p *pbuf) bytesField(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	p.tag(field, wireBytes)
+	p.varint(uint64(len(v)))
+	p.b = append(p.b, v...)
+}
+
+func (p *pbuf) stringField(field int, v string) {
+	if v == "" {
+		return
+	}
+	p.bytesField(field, []byte(v))
+}
+
+// messageField appends msg as a length-delimited nested message.
+func (p *pbuf) messageField(field int, msg *pbuf) {
+	if msg == nil || len(msg.b) == 0 {
+		return
+	}
+	p.bytesField(field, msg.b)
+}
+
+// span field numbers, per opentelemetry.proto.trace.v1.Span.
+const (
+	spanFieldTraceID           = 1
+	spanFieldSpanID            = 2
+	spanFieldName              = 5
+	spanFieldKind              = 6
+	spanFieldStartTimeUnixNano = 7
+	spanFieldEndTimeUnixNano   = 8
+	spanFieldParentSpanID      = 4
+	spanFieldEvents            = 11
+	spanFieldStatus            = 15
+)
+
+// event field numbers, per opentelemetry.proto.trace.v1.Span.Event.
+const (
+	eventFieldTimeUnixNano = 1
+	eventFieldName         = 2
+)
+
+// status field numbers, per opentelemetry.proto.trace.v1.Status.
+const (
+	statusFieldMessage = 2
+	statusFieldCode    = 3
+
+	statusCodeOk    = 1
+	statusCodeError = 2
+)
+
+// scopeSpans / resourceSpans field numbers.
+const (
+	scopeSpansFieldSpans    = 2
+	resourceSpansFieldScope = 2
+	tracesDataFieldResource = 1
+)
+
+// encodeSpan builds one opentelemetry.proto.trace.v1.Span message.
+func encodeSpan(s span) []byte {
+	p := &pbuf{}
+	p.bytesField(spanFieldTraceID, s.traceID[:])
+	p.bytesField(spanFieldSpanID, s.spanID[:])
+	if s.parentSpanID != nil {
+		p.bytesField(spanFieldParentSpanID, s.parentSpanID[:])
+	}
+	p.stringField(spanFieldName, s.name)
+	p.int64Field(spanFieldKind, int64(s.kind))
+	p.fixed64Field(spanFieldStartTimeUnixNano, s.startUnixNano)
+	p.fixed64Field(spanFieldEndTimeUnixNano, s.endUnixNano)
+	p.b = append(p.b, s.events...) // already tag+length-prefixed, see encodeEvent
+	if s.statusErr != "" {
+		status := &pbuf{}
+		status.stringField(statusFieldMessage, s.statusErr)
+		status.int64Field(statusFieldCode, statusCodeError)
+		p.messageField(spanFieldStatus, status)
+	}
+	return p.b
+}
+
+// encodeEvent builds one opentelemetry.proto.trace.v1.Span.Event message,
+// already wrapped as a spanFieldEvents entry so callers can simply
+// concatenate the result onto a span's events.
+func encodeEvent(timeUnixNano uint64, name string) []byte {
+	ev := &pbuf{}
+	ev.fixed64Field(eventFieldTimeUnixNano, timeUnixNano)
+	ev.stringField(eventFieldName, name)
+
+	p := &pbuf{}
+	p.bytesField(spanFieldEvents, ev.b)
+	return p.b
+}
+
+// encodeTracesData wraps the given spans into a single
+// opentelemetry.proto.trace.v1.TracesData message with one ResourceSpans
+// and one ScopeSpans, which is all a batching exporter needs.
+func encodeTracesData(spans []span) []byte {
+	scopeSpans := &pbuf{}
+	for _, s := range spans {
+		scopeSpans.bytesField(scopeSpansFieldSpans, encodeSpan(s))
+	}
+
+	resourceSpans := &pbuf{}
+	resourceSpans.messageField(resourceSpansFieldScope, scopeSpans)
+
+	root := &pbuf{}
+	root.messageField(tracesDataFieldResource, resourceSpans)
+	return root.b
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import "testing"
+
+// decodedField is one field parsed off the wire by decodeMessage, keyed by
+// field number; wireBytes fields keep their raw bytes (for nested messages
+// or strings), everything else keeps its raw varint/fixed64 value.
+type decodedField struct {
+	wire  int
+	bytes []byte
+	value uint64
+}
+
+// decodeMessage is a minimal, test-only protobuf wire-format walker: just
+// enough to assert that encodeSpan/encodeEvent put bytes on the field
+// numbers this package claims, without pulling in a real protobuf library.
+func decodeMessage(t *testing.T, b []byte) map[int][]decodedField {
+	t.Helper()
+	fields := map[int][]decodedField{}
+	for len(b) > 0 {
+		tag, n := decodeVarint(t, b)
+		b = b[n:]
+		field := int(tag >> 3)
+		wire := int(tag & 7)
+		switch wire {
+		case wireVarint:
+			v, n := decodeVarint(t, b)
+			b = b[n:]
+			fields[field] = append(fields[field], decodedField{wire: wire, value: v})
+		case wireFixed64:
+			if len(b) < 8 {
+				t.Fatalf("truncated fixed64 for field %d", field)
+			}
+			var v uint64
+			for i := 7; i >= 0; i-- {
+				v = v<<8 | uint64(b[i])
+			}
+			fields[field] = append(fields[field], decodedField{wire: wire, value: v})
+			b = b[8:]
+		case wireBytes:
+			l, n := decodeVarint(t, b)
+			b = b[n:]
+			if uint64(len(b)) < l {
+				t.Fatalf("truncated bytes field %d: want %d have %d", field, l, len(b))
+			}
+			fields[field] = append(fields[field], decodedField{wire: wire, bytes: append([]byte(nil), b[:l]...)})
+			b = b[l:]
+		default:
+			t.Fatalf("unsupported wire type %d for field %d", wire, field)
+		}
+	}
+	return fields
+}
+
+func decodeVarint(t *testing.T, b []byte) (uint64, int) {
+	t.Helper()
+	var v uint64
+	var shift uint
+	for i, c := range b {
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, i + 1
+		}
+		shift += 7
+	}
+	t.Fatalf("truncated varint")
+	return 0, 0
+}
+
+func TestEncodeSpanEventsLandOnField11(t *testing.T) {
+	s := span{
+		name:          "handleRequest",
+		startUnixNano: 1,
+		endUnixNano:   2,
+		events:        encodeEvent(1, "cache miss"),
+	}
+	fields := decodeMessage(t, encodeSpan(s))
+
+	if _, ok := fields[10]; ok {
+		t.Fatalf("field 10 is dropped_attributes_count, not events; encodeSpan must not write to it")
+	}
+	events, ok := fields[11]
+	if !ok || len(events) != 1 {
+		t.Fatalf("expected exactly one field-11 (events) entry, got %v", fields[11])
+	}
+
+	eventFields := decodeMessage(t, events[0].bytes)
+	nameEntries, ok := eventFields[eventFieldName]
+	if !ok || len(nameEntries) != 1 {
+		t.Fatalf("expected one name field in the decoded event, got %v", eventFields)
+	}
+	if got := string(nameEntries[0].bytes); got != "cache miss" {
+		t.Errorf("event name = %q, want %q", got, "cache miss")
+	}
+}
+
+func TestEncodeSpanRoundTripsCoreFields(t *testing.T) {
+	s := span{
+		traceID:       [16]byte{0: 1, 15: 2},
+		spanID:        [8]byte{0: 3, 7: 4},
+		name:          "op",
+		startUnixNano: 100,
+		endUnixNano:   200,
+	}
+	fields := decodeMessage(t, encodeSpan(s))
+
+	if got := fields[spanFieldTraceID][0].bytes; string(got) != string(s.traceID[:]) {
+		t.Errorf("trace id = %x, want %x", got, s.traceID[:])
+	}
+	if got := fields[spanFieldSpanID][0].bytes; string(got) != string(s.spanID[:]) {
+		t.Errorf("span id = %x, want %x", got, s.spanID[:])
+	}
+	if got := string(fields[spanFieldName][0].bytes); got != "op" {
+		t.Errorf("name = %q, want %q", got, "op")
+	}
+	if got := fields[spanFieldStartTimeUnixNano][0].value; got != 100 {
+		t.Errorf("start = %d, want 100", got)
+	}
+	if got := fields[spanFieldEndTimeUnixNano][0].value; got != 200 {
+		t.Errorf("end = %d, want 200", got)
+	}
+}
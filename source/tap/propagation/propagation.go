@@ -0,0 +1,196 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package propagation implements the W3C Trace Context spec
+// (https://www.w3.org/TR/trace-context/), version "00", so that tap scopes
+// can carry a trace across process boundaries via the standard
+// "traceparent"/"tracestate" headers.
+package propagation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// TraceID is a 128-bit trace identifier.
+type TraceID [16]byte
+
+// String returns the lower-case 32 hex digit form used on the wire.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero returns true for the all-zero TraceID, which the spec forbids on
+// the wire and which this package uses as its "no id" sentinel.
+func (id TraceID) IsZero() bool {
+	return id == TraceID{}
+}
+
+// MarshalJSON renders the TraceID as its 32 hex digit string form.
+func (id TraceID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.String() + `"`), nil
+}
+
+// SpanID is a 64-bit span identifier.
+type SpanID [8]byte
+
+// String returns the lower-case 16 hex digit form used on the wire.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero returns true for the all-zero SpanID, which the spec forbids on
+// the wire and which this package uses as its "no id" sentinel.
+func (id SpanID) IsZero() bool {
+	return id == SpanID{}
+}
+
+// NewTraceID generates a random TraceID using crypto/rand, suitable for
+// starting a new trace that has no incoming traceparent to inherit from.
+func NewTraceID() TraceID {
+	var id TraceID
+	// crypto/rand.Read on the package-level Reader never returns a short
+	// read without an error, and an error here only happens if the OS
+	// entropy source is broken, which we can't usefully recover from.
+	if _, err := rand.Read(id[:]); err != nil {
+		panic("tap/propagation: failed to read random trace id: " + err.Error())
+	}
+	return id
+}
+
+// SpanContext is a remote trace/span id pair extracted from an incoming
+// traceparent header, plus the sampled flag carried in its trace-flags
+// byte.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if both the trace id and span id are non-zero, per
+// the spec's validity rules.
+func (sc SpanContext) IsValid() bool {
+	return !sc.TraceID.IsZero() && !sc.SpanID.IsZero()
+}
+
+const (
+	version   = "00"
+	versionSz = 2
+	traceSz   = 32
+	spanSz    = 16
+	flagsSz   = 2
+	// "00" + "-" + 32 hex + "-" + 16 hex + "-" + 2 hex
+	traceparentLen = versionSz + 1 + traceSz + 1 + spanSz + 1 + flagsSz
+)
+
+// ErrMalformedTraceParent is returned by ParseTraceParent when the header
+// does not match the "00-<trace-id>-<span-id>-<flags>" shape.
+var ErrMalformedTraceParent = errors.New("propagation: malformed traceparent header")
+
+// ParseTraceParent parses a W3C "traceparent" header value.  Only version
+// "00" is understood; per the spec, unknown versions should still be
+// parsed best-effort using the version-00 field layout, which is what this
+// does as long as the header is at least as long as a version-00 header.
+func ParseTraceParent(header string) (SpanContext, error) {
+	var sc SpanContext
+	if len(header) < traceparentLen {
+		return sc, ErrMalformedTraceParent
+	}
+	fields := strings.Split(header[:traceparentLen], "-")
+	if len(fields) != 4 {
+		return sc, ErrMalformedTraceParent
+	}
+	ver, traceID, spanID, flags := fields[0], fields[1], fields[2], fields[3]
+	if len(ver) != versionSz || len(traceID) != traceSz || len(spanID) != spanSz || len(flags) != flagsSz {
+		return sc, ErrMalformedTraceParent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return SpanContext{}, ErrMalformedTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return SpanContext{}, ErrMalformedTraceParent
+	}
+	var flagByte [1]byte
+	if _, err := hex.Decode(flagByte[:], []byte(flags)); err != nil {
+		return SpanContext{}, ErrMalformedTraceParent
+	}
+	sc.Sampled = flagByte[0]&0x1 == 1
+	if !sc.IsValid() {
+		return SpanContext{}, ErrMalformedTraceParent
+	}
+	return sc, nil
+}
+
+// TraceParent formats sc as a W3C "traceparent" header value.
+func (sc SpanContext) TraceParent() string {
+	flags := byte(0)
+	if sc.Sampled {
+		flags = 1
+	}
+	return version + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{flags})
+}
+
+// Carrier abstracts a place to read and write propagation headers, so that
+// Inject/Extract work unmodified with http.Header (which already has
+// matching Get/Set methods), gRPC metadata.MD (via a small adapter), or a
+// plain map in tests.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// MapCarrier adapts a plain map[string]string to Carrier.
+type MapCarrier map[string]string
+
+// Get implements Carrier.
+func (m MapCarrier) Get(key string) string { return m[key] }
+
+// Set implements Carrier.
+func (m MapCarrier) Set(key, value string) { m[key] = value }
+
+// Header/tracestate key names, per the spec; exported so transports that
+// can't use the Carrier interface directly (e.g. gRPC metadata, which
+// lower-cases and may repeat keys) can still read/write the right names.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// Inject writes sc's traceparent (and tracestate, if non-empty) into
+// carrier.
+func Inject(sc SpanContext, tracestate string, carrier Carrier) {
+	carrier.Set(TraceParentHeader, sc.TraceParent())
+	if tracestate != "" {
+		carrier.Set(TraceStateHeader, tracestate)
+	}
+}
+
+// Extract reads a traceparent (and tracestate) from carrier.  The returned
+// SpanContext's IsValid is false, and tracestate is "", if no valid
+// traceparent was present.
+func Extract(carrier Carrier) (sc SpanContext, tracestate string) {
+	sc, err := ParseTraceParent(carrier.Get(TraceParentHeader))
+	if err != nil {
+		return SpanContext{}, ""
+	}
+	return sc, carrier.Get(TraceStateHeader)
+}
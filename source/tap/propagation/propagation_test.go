@@ -0,0 +1,109 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package propagation
+
+import "testing"
+
+func TestParseTraceParentValid(t *testing.T) {
+	const header = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	sc, err := ParseTraceParent(header)
+	if err != nil {
+		t.Fatalf("ParseTraceParent(%q) = %v, want nil error", header, err)
+	}
+	if !sc.IsValid() {
+		t.Fatalf("ParseTraceParent(%q) = %+v, want valid", header, sc)
+	}
+	if got, want := sc.TraceID.String(), "0af7651916cd43dd8448eb211c80319c"; got != want {
+		t.Errorf("TraceID = %s, want %s", got, want)
+	}
+	if got, want := sc.SpanID.String(), "b7ad6b7169203331"; got != want {
+		t.Errorf("SpanID = %s, want %s", got, want)
+	}
+	if !sc.Sampled {
+		t.Errorf("Sampled = false, want true for flags 01")
+	}
+	if got := sc.TraceParent(); got != header {
+		t.Errorf("round-trip TraceParent() = %q, want %q", got, header)
+	}
+}
+
+func TestParseTraceParentNotSampled(t *testing.T) {
+	sc, err := ParseTraceParent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	if err != nil {
+		t.Fatalf("ParseTraceParent() = %v, want nil error", err)
+	}
+	if sc.Sampled {
+		t.Errorf("Sampled = true, want false for flags 00")
+	}
+}
+
+func TestParseTraceParentMalformed(t *testing.T) {
+	cases := map[string]string{
+		"empty":                  "",
+		"too short":              "00-0af7651916cd43dd8448eb211c80319c-b7ad6b71692033",
+		"truncated mid-trace-id": "00-0af7651916cd43dd8448eb211c8031",
+		"wrong field count":      "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"non-hex trace id":       "00-zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz-b7ad6b7169203331-01",
+		"non-hex span id":        "00-0af7651916cd43dd8448eb211c80319c-zzzzzzzzzzzzzzzz-01",
+		"non-hex flags":          "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-zz",
+		"all-zero trace id":      "00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"all-zero span id":       "00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+	}
+	for name, header := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseTraceParent(header); err != ErrMalformedTraceParent {
+				t.Errorf("ParseTraceParent(%q) error = %v, want ErrMalformedTraceParent", header, err)
+			}
+		})
+	}
+}
+
+func TestNewTraceIDNotZero(t *testing.T) {
+	id := NewTraceID()
+	if id.IsZero() {
+		t.Fatal("NewTraceID() returned the zero TraceID")
+	}
+}
+
+func TestInjectExtractRoundTrip(t *testing.T) {
+	sc := SpanContext{TraceID: NewTraceID(), SpanID: SpanID{1, 2, 3, 4, 5, 6, 7, 8}, Sampled: true}
+	carrier := MapCarrier{}
+
+	Inject(sc, "vendor=state", carrier)
+
+	got, tracestate := Extract(carrier)
+	if got != sc {
+		t.Errorf("Extract() = %+v, want %+v", got, sc)
+	}
+	if tracestate != "vendor=state" {
+		t.Errorf("tracestate = %q, want %q", tracestate, "vendor=state")
+	}
+}
+
+func TestExtractNoHeader(t *testing.T) {
+	sc, tracestate := Extract(MapCarrier{})
+	if sc.IsValid() {
+		t.Errorf("Extract() on empty carrier = %+v, want invalid", sc)
+	}
+	if tracestate != "" {
+		t.Errorf("tracestate = %q, want empty", tracestate)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"time"
+
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+// SpanRecord is the exported, structured view of one record emitted by a
+// Tracer.  record itself stays unexported (its shape is an implementation
+// detail of the gwr text/json formats), but a custom source.GenericDataWatcher
+// that wants to reassemble traces out of the raw item stream - such as
+// tap/memstore - can recover one via AsRecord instead of depending on
+// record's internal layout.
+type SpanRecord struct {
+	Time      time.Time
+	Type      string
+	TraceID   propagation.TraceID
+	ScopeID   uint64
+	SpanID    uint64
+	ParentID  uint64
+	HasParent bool
+	Name      string
+
+	// Fields holds the structured attributes of an InfoKV/OpenKV/CloseKV/
+	// ErrorKV call, if that's how this record was produced; it is nil
+	// otherwise.
+	Fields map[string]interface{}
+
+	// Err is the error message of an Error/ErrorName/ErrorKV/ErrorNameKV
+	// record; it is "" otherwise.
+	Err string
+}
+
+// AsRecord extracts a SpanRecord from item, which should be a value handed
+// to a Tracer's watcher via GenericDataWatcher.HandleItem.  ok is false if
+// item did not originate from a Tracer.
+func AsRecord(item interface{}) (sr SpanRecord, ok bool) {
+	rec, ok := item.(*record)
+	if !ok {
+		return SpanRecord{}, false
+	}
+	sr = SpanRecord{
+		Time:    rec.Time,
+		Type:    rec.Type.String(),
+		TraceID: rec.TraceId,
+		ScopeID: rec.ScopeId,
+		SpanID:  rec.SpanId,
+		Name:    rec.Name,
+	}
+	if rec.ParentId != nil {
+		sr.ParentID = *rec.ParentId
+		sr.HasParent = true
+	}
+	switch args := rec.Args.(type) {
+	case fieldArgs:
+		sr.Fields = args.Map()
+	case errArgs:
+		sr.Err = args.err.Error()
+		if fa, ok := args.extra.(fieldArgs); ok {
+			sr.Fields = fa.Map()
+		}
+	}
+	return sr, true
+}
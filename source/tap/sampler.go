@@ -0,0 +1,185 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+// SamplingDecision is the result of a Sampler's decision for a scope.
+type SamplingDecision uint8
+
+const (
+	// Drop means the scope should be a no-op: its Open/Info/Close/Error
+	// calls do no work and emit nothing.
+	Drop SamplingDecision = iota
+	// RecordAndSample means the scope behaves as scopes always have:
+	// records are built and emitted to the tracer's watcher/exporter.
+	RecordAndSample
+)
+
+// Sampler decides whether a new scope should actually record, letting
+// production users keep tracing "on" while only paying for and storing a
+// subset of it.  This is a separate, finer-grained control than
+// Tracer.Active(), which is all-or-nothing.
+type Sampler interface {
+	// ShouldSample is called once, when a scope is created.  parent is
+	// the immediate parent scope, or nil when creating a root scope.
+	ShouldSample(parent *TraceScope, name string) SamplingDecision
+}
+
+type samplerFunc func(parent *TraceScope, name string) SamplingDecision
+
+func (f samplerFunc) ShouldSample(parent *TraceScope, name string) SamplingDecision {
+	return f(parent, name)
+}
+
+// AlwaysSample returns a Sampler that samples every scope; this is the
+// default when a Tracer has no sampler configured.
+func AlwaysSample() Sampler {
+	return samplerFunc(func(*TraceScope, string) SamplingDecision { return RecordAndSample })
+}
+
+// NeverSample returns a Sampler that samples nothing.
+func NeverSample() Sampler {
+	return samplerFunc(func(*TraceScope, string) SamplingDecision { return Drop })
+}
+
+// TraceIDRatioBased returns a Sampler that samples a deterministic fraction
+// p (0 <= p <= 1) of traces: every scope within a given trace gets the same
+// decision, because the decision is derived from the trace id rather than
+// rolled independently per scope.
+func TraceIDRatioBased(p float64) Sampler {
+	if p <= 0 {
+		return NeverSample()
+	}
+	if p >= 1 {
+		return AlwaysSample()
+	}
+	threshold := uint64(p * float64(math.MaxUint64))
+	return samplerFunc(func(parent *TraceScope, name string) SamplingDecision {
+		var key uint64
+		if parent != nil {
+			key = traceIDKey(parent.top.traceID)
+		} else {
+			// No trace id exists yet for a root scope; a fresh random key
+			// is an equivalent distribution, since the trace id about to
+			// be minted is itself uniformly random.
+			key = randomUint64()
+		}
+		if key < threshold {
+			return RecordAndSample
+		}
+		return Drop
+	})
+}
+
+// ParentBased returns a Sampler that inherits the parent scope's sampling
+// decision, if there is a parent.  For root scopes (no parent), it
+// delegates to root.
+func ParentBased(root Sampler) Sampler {
+	return samplerFunc(func(parent *TraceScope, name string) SamplingDecision {
+		if parent != nil {
+			if parent.sampled {
+				return RecordAndSample
+			}
+			return Drop
+		}
+		return root.ShouldSample(parent, name)
+	})
+}
+
+// RateLimited returns a Sampler that samples at most perSec scopes per
+// second, using a token bucket so brief bursts up to perSec are allowed
+// without smoothing them away.
+func RateLimited(perSec int) Sampler {
+	lim := &rateLimiter{
+		tokens: float64(perSec),
+		max:    float64(perSec),
+		rate:   float64(perSec),
+		last:   time.Now(),
+	}
+	return samplerFunc(func(*TraceScope, string) SamplingDecision {
+		if lim.take() {
+			return RecordAndSample
+		}
+		return Drop
+	})
+}
+
+type rateLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	max    float64
+	rate   float64
+	last   time.Time
+}
+
+func (l *rateLimiter) take() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.max {
+		l.tokens = l.max
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// SetSampler sets the Sampler used to decide whether newly created scopes
+// record; nil (the default) is equivalent to AlwaysSample.
+func (src *Tracer) SetSampler(s Sampler) {
+	src.sampler = s
+}
+
+func (src *Tracer) shouldSample(parent *TraceScope, name string) bool {
+	s := src.sampler
+	if s == nil {
+		s = AlwaysSample()
+	}
+	return s.ShouldSample(parent, name) == RecordAndSample
+}
+
+// traceIDKey collapses a 128-bit TraceID down to a uint64 for ratio
+// comparisons, using its low 8 bytes.  NewTraceID fills all 16 bytes at
+// random, so those 8 bytes are uniformly distributed for locally minted ids.
+func traceIDKey(id propagation.TraceID) uint64 {
+	return binary.BigEndian.Uint64(id[8:])
+}
+
+func randomUint64() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("tap: failed to read random sampling key: " + err.Error())
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+func TestAlwaysNeverSample(t *testing.T) {
+	if got := AlwaysSample().ShouldSample(nil, "x"); got != RecordAndSample {
+		t.Errorf("AlwaysSample() = %v, want RecordAndSample", got)
+	}
+	if got := NeverSample().ShouldSample(nil, "x"); got != Drop {
+		t.Errorf("NeverSample() = %v, want Drop", got)
+	}
+}
+
+// scopeWithKey builds a root TraceScope whose trace id's low 8 bytes are
+// key, for driving TraceIDRatioBased's threshold comparison deterministically.
+func scopeWithKey(key uint64) *TraceScope {
+	sc := &TraceScope{}
+	sc.top = sc
+	for i := 0; i < 8; i++ {
+		sc.traceID[8+i] = byte(key >> (56 - 8*i))
+	}
+	return sc
+}
+
+func TestTraceIDRatioBasedDegenerate(t *testing.T) {
+	if got := TraceIDRatioBased(0).ShouldSample(scopeWithKey(0), "x"); got != Drop {
+		t.Errorf("TraceIDRatioBased(0) = %v, want Drop", got)
+	}
+	if got := TraceIDRatioBased(1).ShouldSample(scopeWithKey(^uint64(0)), "x"); got != RecordAndSample {
+		t.Errorf("TraceIDRatioBased(1) = %v, want RecordAndSample", got)
+	}
+}
+
+func TestTraceIDRatioBasedIsDeterministicPerTrace(t *testing.T) {
+	s := TraceIDRatioBased(0.5)
+	low := scopeWithKey(0)
+	high := scopeWithKey(^uint64(0))
+
+	if got := s.ShouldSample(low, "a"); got != RecordAndSample {
+		t.Errorf("low key = %v, want RecordAndSample", got)
+	}
+	if got := s.ShouldSample(low, "b"); got != RecordAndSample {
+		t.Errorf("same trace id queried again = %v, want RecordAndSample (deterministic)", got)
+	}
+	if got := s.ShouldSample(high, "a"); got != Drop {
+		t.Errorf("high key = %v, want Drop", got)
+	}
+}
+
+func TestParentBasedInheritsParent(t *testing.T) {
+	s := ParentBased(AlwaysSample())
+
+	sampledParent := scopeWithKey(0)
+	sampledParent.sampled = true
+	if got := s.ShouldSample(sampledParent, "child"); got != RecordAndSample {
+		t.Errorf("sampled parent = %v, want RecordAndSample", got)
+	}
+
+	droppedParent := scopeWithKey(0)
+	droppedParent.sampled = false
+	if got := s.ShouldSample(droppedParent, "child"); got != Drop {
+		t.Errorf("dropped parent = %v, want Drop", got)
+	}
+}
+
+func TestParentBasedDelegatesRootToRootSampler(t *testing.T) {
+	if got := ParentBased(NeverSample()).ShouldSample(nil, "root"); got != Drop {
+		t.Errorf("root with NeverSample root sampler = %v, want Drop", got)
+	}
+	if got := ParentBased(AlwaysSample()).ShouldSample(nil, "root"); got != RecordAndSample {
+		t.Errorf("root with AlwaysSample root sampler = %v, want RecordAndSample", got)
+	}
+}
+
+func TestRateLimitedAllowsBurstThenThrottles(t *testing.T) {
+	s := RateLimited(3)
+	for i := 0; i < 3; i++ {
+		if got := s.ShouldSample(nil, "x"); got != RecordAndSample {
+			t.Fatalf("burst call %d = %v, want RecordAndSample", i, got)
+		}
+	}
+	if got := s.ShouldSample(nil, "x"); got != Drop {
+		t.Errorf("call beyond burst = %v, want Drop", got)
+	}
+
+	time.Sleep(400 * time.Millisecond) // 3/sec -> at least ~1 token back
+	if got := s.ShouldSample(nil, "x"); got != RecordAndSample {
+		t.Errorf("call after replenish = %v, want RecordAndSample", got)
+	}
+}
+
+func TestTraceIDKeyUsesLow8Bytes(t *testing.T) {
+	var id propagation.TraceID
+	for i := 0; i < 8; i++ {
+		id[8+i] = byte(i + 1)
+	}
+	if got, want := traceIDKey(id), uint64(0x0102030405060708); got != want {
+		t.Errorf("traceIDKey = %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"time"
+
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+// SpanData is an immutable snapshot of a TraceScope at the time a
+// SpanExporter method is called.  Its fields mirror the usual shape of an
+// OpenTelemetry span: TraceID is the scope's root trace id - the same 128-bit
+// id carried by an inbound/outbound W3C traceparent, so two services
+// exporting the same distributed call line up in a collector - SpanID
+// identifies this scope, and ParentID is zero for root scopes; a root scope
+// that continues an upstream trace carries that link in RemoteParentID.
+type SpanData struct {
+	TraceID  propagation.TraceID
+	SpanID   uint64
+	ParentID uint64
+
+	// RemoteParentID is the upstream span id for a root scope that
+	// continues a trace started elsewhere - an inbound W3C traceparent
+	// (Extract/ContextWithRemote), or a ScopeFromContext call continuing a
+	// scope from a different Tracer.  It is the zero SpanID otherwise,
+	// including for any non-root scope, which links to its local parent
+	// via ParentID instead.
+	RemoteParentID propagation.SpanID
+
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// SpanExporter receives span-shaped events derived from trace scopes, in
+// addition to (not instead of) the regular gwr record stream a Tracer's
+// watcher sees.  Implementations are usually called synchronously from the
+// scope methods (Open, Info, Close, Error, ...), so they should do any slow
+// work (network I/O, batching) asynchronously themselves; see the otlp
+// sub-package for an example.  The exception is a scope with WithDeadline
+// set: if its deadline elapses first, SetStatus and EndSpan are called from
+// the package's background deadline-scheduler goroutine instead of the
+// caller's, so implementations must tolerate being called from more than one
+// goroutine.
+type SpanExporter interface {
+	// StartSpan is called on every Open/OpenCall of a scope, so once for a
+	// scope that is opened once.
+	StartSpan(span SpanData)
+
+	// AddEvent is called for every Info on a scope, after StartSpan and
+	// before EndSpan.
+	AddEvent(span SpanData, name string, args interface{})
+
+	// SetStatus is called when a scope reports an error via Error or
+	// ErrorName, immediately before the resulting EndSpan.
+	SetStatus(span SpanData, err error)
+
+	// EndSpan is called on every Close, CloseCall, Error, or ErrorName of a
+	// scope; a scope that reports more than one error, or errors and then
+	// closes, gets more than one EndSpan call.
+	EndSpan(span SpanData)
+}
+
+// spanData builds the SpanData snapshot for this scope.
+func (sc *TraceScope) spanData() SpanData {
+	var parentID uint64
+	if sc.parent != nil {
+		parentID = sc.parent.id
+	}
+	return SpanData{
+		TraceID:        sc.top.traceID,
+		SpanID:         sc.id,
+		ParentID:       parentID,
+		RemoteParentID: sc.remoteParent,
+		Name:           sc.name,
+		Start:          sc.begin,
+		End:            sc.end,
+	}
+}
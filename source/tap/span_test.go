@@ -0,0 +1,113 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uber-go/gwr/source/tap/propagation"
+)
+
+// captureExporter records the SpanData passed to StartSpan, for asserting
+// on the parent linkage a SpanExporter sees.
+type captureExporter struct {
+	started []SpanData
+}
+
+func (e *captureExporter) StartSpan(sd SpanData)                  { e.started = append(e.started, sd) }
+func (e *captureExporter) AddEvent(SpanData, string, interface{}) {}
+func (e *captureExporter) SetStatus(SpanData, error)              {}
+func (e *captureExporter) EndSpan(SpanData)                       {}
+
+var _ SpanExporter = (*captureExporter)(nil)
+
+func TestSpanDataCarriesRemoteParentForInboundTrace(t *testing.T) {
+	exp := &captureExporter{}
+	trc := NewTracer("remote-parent-test")
+	trc.SetSpanExporter(exp)
+
+	upstream := propagation.SpanContext{
+		TraceID: propagation.NewTraceID(),
+		SpanID:  propagation.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		Sampled: true,
+	}
+	ctx := ContextWithRemote(context.Background(), upstream)
+
+	sc, _ := trc.ScopeFromContext(ctx, "handle")
+	sc.Open()
+
+	if len(exp.started) != 1 {
+		t.Fatalf("got %d StartSpan calls, want 1", len(exp.started))
+	}
+	sd := exp.started[0]
+	if sd.ParentID != 0 {
+		t.Errorf("ParentID = %d, want 0 for a root scope", sd.ParentID)
+	}
+	if sd.RemoteParentID != upstream.SpanID {
+		t.Errorf("RemoteParentID = %x, want upstream span id %x", sd.RemoteParentID, upstream.SpanID)
+	}
+}
+
+func TestSpanDataCarriesRemoteParentAcrossTracers(t *testing.T) {
+	upstreamExp := &captureExporter{}
+	upstream := NewTracer("upstream")
+	upstream.SetSpanExporter(upstreamExp)
+
+	downstreamExp := &captureExporter{}
+	downstream := NewTracer("downstream")
+	downstream.SetSpanExporter(downstreamExp)
+
+	_, ctx := upstream.ScopeFromContext(context.Background(), "handle")
+	sc, _ := downstream.ScopeFromContext(ctx, "db.query")
+	sc.Open()
+
+	if len(downstreamExp.started) != 1 {
+		t.Fatalf("got %d StartSpan calls on the downstream exporter, want 1", len(downstreamExp.started))
+	}
+	sd := downstreamExp.started[0]
+	if sd.ParentID != 0 {
+		t.Errorf("ParentID = %d, want 0: the parent is on a different Tracer", sd.ParentID)
+	}
+	if sd.RemoteParentID.IsZero() {
+		t.Error("RemoteParentID is zero, want the upstream scope's span id")
+	}
+}
+
+func TestSpanDataHasNoRemoteParentForLocalChild(t *testing.T) {
+	exp := &captureExporter{}
+	trc := NewTracer("local-child-test")
+	trc.SetSpanExporter(exp)
+
+	root := trc.Scope("root").Open()
+	root.Sub("child").Open()
+
+	if len(exp.started) != 2 {
+		t.Fatalf("got %d StartSpan calls, want 2", len(exp.started))
+	}
+	child := exp.started[1]
+	if child.ParentID != root.id {
+		t.Errorf("child ParentID = %d, want root id %d", child.ParentID, root.id)
+	}
+	if !child.RemoteParentID.IsZero() {
+		t.Errorf("RemoteParentID = %x, want zero for a locally-parented child", child.RemoteParentID)
+	}
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/uber-go/gwr"
 	"github.com/uber-go/gwr/source"
+	"github.com/uber-go/gwr/source/tap/propagation"
 )
 
 const (
@@ -41,28 +42,28 @@ const (
 // Tracers should be created for each area of the application that can be
 // traced.  This could be as simple as creating a package-level tracer:
 //
-//     package foo
+//	package foo
 //
-//     import "github.com/uber-go/gwr/source"
+//	import "github.com/uber-go/gwr/source"
 //
-//     tracer := source.AddNewTracer("foo")
+//	tracer := source.AddNewTracer("foo")
 //
 // Tracers can also be attached to parts of the application:
 //
-//     type Thing struct {
-//         t *Tracer
-//     }
+//	type Thing struct {
+//	    t *Tracer
+//	}
 //
-//     func NewThing() *Thing {
-//         // ...
-//         t.tracer = source.AddNewTracer(fmt.Sprintf("foo/%v", someThingIdentifier))
-//         // ...
-//     }
+//	func NewThing() *Thing {
+//	    // ...
+//	    t.tracer = source.AddNewTracer(fmt.Sprintf("foo/%v", someThingIdentifier))
+//	    // ...
+//	}
 //
 // If Things are not the same life-cycle as the application, then they should
 // have teardown code to remove their tracer data sources:
 //
-//     gwr.DefaultDataSources.Remove(t.tracer.Name())
+//	gwr.DefaultDataSources.Remove(t.tracer.Name())
 //
 // You can then proceed to trace your functions and methods.  First decide
 // where/what you want to start tracing.  this will probably be one or more
@@ -86,17 +87,19 @@ const (
 //
 // You can similarly trace a worker goroutine:
 //
-//     ch := make(chan int)
-//     go func() {
-//         for n := range ch {
-//             scope := tracer.Scope("n <- workerChan").Open(n)
-//             // do something...
-//             scope.Close()
-//         }
-//     }()
+//	ch := make(chan int)
+//	go func() {
+//	    for n := range ch {
+//	        scope := tracer.Scope("n <- workerChan").Open(n)
+//	        // do something...
+//	        scope.Close()
+//	    }
+//	}()
 type Tracer struct {
-	name    string
-	watcher source.GenericDataWatcher
+	name     string
+	watcher  source.GenericDataWatcher
+	exporter SpanExporter
+	sampler  Sampler
 }
 
 // NewTracer creates a Tracer with a given name.
@@ -142,11 +145,25 @@ func (src *Tracer) Formats() map[string]source.GenericDataFormat {
 	}
 }
 
-// SetWatcher sets the current watcher.
+// SetWatcher sets the current watcher.  HandleItem is usually called from
+// whatever goroutine is driving the scope (Open, Info, Close, ...), but a
+// scope with WithDeadline set can also fire its "deadline_exceeded" error
+// record from the package's
+// background deadline-scheduler goroutine if the deadline elapses before the
+// scope finishes - so a watcher must be safe to call concurrently with itself.
 func (src *Tracer) SetWatcher(watcher source.GenericDataWatcher) {
 	src.watcher = watcher
 }
 
+// SetSpanExporter sets a SpanExporter that every scope on this tracer will
+// additionally feed span-shaped events to.  This is independent of, and in
+// addition to, the gwr watcher set by SetWatcher: existing gwr consumers keep
+// working unchanged, while a SpanExporter lets the same trace data reach an
+// OpenTelemetry-compatible backend (see the otlp sub-package).
+func (src *Tracer) SetSpanExporter(exp SpanExporter) {
+	src.exporter = exp
+}
+
 // Scope creates a new named trace scope
 func (src *Tracer) Scope(name string) *TraceScope {
 	return newScope(src, nil, name)
@@ -202,6 +219,23 @@ type TraceScope struct {
 	name   string
 	begin  time.Time
 	end    time.Time
+
+	// traceID is only meaningful on a root scope (top == sc); non-root
+	// scopes read it via sc.top.traceID.  It is either minted fresh, or
+	// inherited from a remote traceparent by newRemoteScope.
+	traceID propagation.TraceID
+	// remoteParent is the span id of the remote scope that started this
+	// trace, if any; it is the zero SpanID for locally-started traces.
+	remoteParent propagation.SpanID
+	// sampled is the Sampler's decision for this scope, made once at
+	// creation.  When false, emitRecord is a no-op: this is the fast path
+	// that lets tracing stay "on" while only a subset is captured.
+	sampled bool
+
+	// finished and deadline back Finish/WithDeadline/StartScope; see
+	// deferred.go.
+	finished int32
+	deadline *deadlineEntry
 }
 
 func newScope(trc *Tracer, parent *TraceScope, name string) *TraceScope {
@@ -215,7 +249,9 @@ func newScope(trc *Tracer, parent *TraceScope, name string) *TraceScope {
 		sc.top = parent.top
 	} else {
 		sc.top = sc
+		sc.traceID = propagation.NewTraceID()
 	}
+	sc.sampled = trc.shouldSample(parent, name)
 	return sc
 }
 
@@ -289,6 +325,9 @@ func (sc *TraceScope) CloseCall(rets ...interface{}) *TraceScope {
 }
 
 func (sc *TraceScope) emitRecord(t recordType, args interface{}) *TraceScope {
+	if !sc.sampled {
+		return sc
+	}
 	now := time.Now()
 	switch t {
 	case beginRecord:
@@ -307,6 +346,7 @@ func (sc *TraceScope) emitRecord(t recordType, args interface{}) *TraceScope {
 		Type:    t,
 		ScopeId: sc.top.id,
 		SpanId:  sc.id,
+		TraceId: sc.top.traceID,
 		Name:    sc.name,
 		Args:    args,
 	}
@@ -314,9 +354,34 @@ func (sc *TraceScope) emitRecord(t recordType, args interface{}) *TraceScope {
 		rec.ParentId = &sc.parent.id
 	}
 	sc.trc.emit(&rec)
+	sc.exportSpan(t, &rec)
 	return sc
 }
 
+// exportSpan translates a record into the relevant SpanExporter call, if a
+// SpanExporter is configured on this scope's tracer.  It is a no-op
+// otherwise, so tracers that only ever talk gwr pay nothing extra here.
+func (sc *TraceScope) exportSpan(t recordType, rec *record) {
+	exp := sc.trc.exporter
+	if exp == nil {
+		return
+	}
+	span := sc.spanData()
+	switch t {
+	case beginRecord:
+		exp.StartSpan(span)
+	case infoRecord:
+		exp.AddEvent(span, rec.Name, rec.Args)
+	case endRecord:
+		exp.EndSpan(span)
+	case errRecord:
+		if ea, ok := rec.Args.(errArgs); ok {
+			exp.SetStatus(span, ea.err)
+		}
+		exp.EndSpan(span)
+	}
+}
+
 func dumpArgs(args []interface{}) string {
 	// TODO: replace / make better; consider using go-spew
 	parts := make([]string, len(args))
@@ -383,10 +448,12 @@ func (args callRets) String() string {
 	return dumpArgs(args)
 }
 
+// errArgs.extra holds either genericArgs (from Error/ErrorName) or
+// fieldArgs (from ErrorKV/ErrorNameKV); both implement fmt.Stringer.
 type errArgs struct {
 	name  string
 	err   error
-	extra genericArgs
+	extra fmt.Stringer
 }
 
 func (args errArgs) String() string {
@@ -396,20 +463,26 @@ func (args errArgs) String() string {
 	} else {
 		s = fmt.Sprintf("Error(%s)", args.err)
 	}
-	if len(args.extra) > 0 {
+	if args.extra != nil && args.extra.String() != "" {
 		s = fmt.Sprintf("%s %s", s, args.extra)
 	}
 	return s
 }
 
 type record struct {
-	Time     time.Time   `json:"time"`
-	Type     recordType  `json:"type"`
-	ScopeId  uint64      `json:"scope_id"`
-	SpanId   uint64      `json:"span_id"`
-	ParentId *uint64     `json:"parent_id"`
-	Name     string      `json:"name"`
-	Args     interface{} `json:"args"`
+	Time time.Time  `json:"time"`
+	Type recordType `json:"type"`
+	// ScopeId, SpanId, and ParentId are the original process-local uint64
+	// ids, kept for back-compat with existing JSON consumers.  TraceId is
+	// the wider, globally-unique id (see the tap/propagation package)
+	// that makes cross-service traces possible; it's the same for every
+	// record sharing a root scope.
+	ScopeId  uint64              `json:"scope_id"`
+	SpanId   uint64              `json:"span_id"`
+	ParentId *uint64             `json:"parent_id"`
+	TraceId  propagation.TraceID `json:"trace_id"`
+	Name     string              `json:"name"`
+	Args     interface{}         `json:"args"`
 }
 
 func (rec record) IDString() string {